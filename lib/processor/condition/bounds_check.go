@@ -22,6 +22,7 @@ package condition
 
 import (
 	"errors"
+	"unicode/utf8"
 
 	"github.com/Jeffail/benthos/lib/log"
 	"github.com/Jeffail/benthos/lib/metrics"
@@ -43,19 +44,29 @@ BoundsCheck is a condition that checks a message against a set of bounds.`,
 // BoundsCheckConfig contains configuration fields for the BoundsCheck
 // processor.
 type BoundsCheckConfig struct {
-	MaxParts    int `json:"max_parts" yaml:"max_parts"`
-	MinParts    int `json:"min_parts" yaml:"min_parts"`
-	MaxPartSize int `json:"max_part_size" yaml:"max_part_size"`
-	MinPartSize int `json:"min_part_size" yaml:"min_part_size"`
+	MaxParts             int  `json:"max_parts" yaml:"max_parts"`
+	MinParts             int  `json:"min_parts" yaml:"min_parts"`
+	MaxPartSize          int  `json:"max_part_size" yaml:"max_part_size"`
+	MinPartSize          int  `json:"min_part_size" yaml:"min_part_size"`
+	MaxTotalSize         int  `json:"max_total_size" yaml:"max_total_size"`
+	MinTotalSize         int  `json:"min_total_size" yaml:"min_total_size"`
+	MaxMetadataEntries   int  `json:"max_metadata_entries" yaml:"max_metadata_entries"`
+	MaxMetadataValueSize int  `json:"max_metadata_value_size" yaml:"max_metadata_value_size"`
+	RequireUTF8          bool `json:"require_utf8" yaml:"require_utf8"`
 }
 
 // NewBoundsCheckConfig returns a BoundsCheckConfig with default values.
 func NewBoundsCheckConfig() BoundsCheckConfig {
 	return BoundsCheckConfig{
-		MaxParts:    100,
-		MinParts:    1,
-		MaxPartSize: 1 * 1024 * 1024 * 1024, // 1GB
-		MinPartSize: 1,
+		MaxParts:             100,
+		MinParts:             1,
+		MaxPartSize:          1 * 1024 * 1024 * 1024, // 1GB
+		MinPartSize:          1,
+		MaxTotalSize:         0,
+		MinTotalSize:         0,
+		MaxMetadataEntries:   0,
+		MaxMetadataValueSize: 0,
+		RequireUTF8:          false,
 	}
 }
 
@@ -72,11 +83,20 @@ type BoundsCheck struct {
 	minParts    int
 	minPartSize int
 
-	mApplied         metrics.StatCounter
-	mSkipped         metrics.StatCounter
-	mSkippedEmpty    metrics.StatCounter
-	mSkippedNumParts metrics.StatCounter
-	mSkippedPartSize metrics.StatCounter
+	maxTotalSize         int
+	minTotalSize         int
+	maxMetadataEntries   int
+	maxMetadataValueSize int
+	requireUTF8          bool
+
+	mApplied          metrics.StatCounter
+	mSkipped          metrics.StatCounter
+	mSkippedEmpty     metrics.StatCounter
+	mSkippedNumParts  metrics.StatCounter
+	mSkippedPartSize  metrics.StatCounter
+	mSkippedTotalSize metrics.StatCounter
+	mSkippedMetadata  metrics.StatCounter
+	mSkippedEncoding  metrics.StatCounter
 }
 
 // NewBoundsCheck returns a BoundsCheck condition.
@@ -84,17 +104,25 @@ func NewBoundsCheck(
 	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
 ) (Type, error) {
 	return &BoundsCheck{
-		log:              log.NewModule(".condition.bounds_check"),
-		stats:            stats,
-		maxParts:         conf.BoundsCheck.MaxParts,
-		maxPartSize:      conf.BoundsCheck.MaxPartSize,
-		minParts:         conf.BoundsCheck.MinParts,
-		minPartSize:      conf.BoundsCheck.MinPartSize,
-		mApplied:         stats.GetCounter("condition.bounds_check.applied"),
-		mSkipped:         stats.GetCounter("condition.bounds_check.skipped"),
-		mSkippedEmpty:    stats.GetCounter("condition.bounds_check.skipped.empty_message"),
-		mSkippedNumParts: stats.GetCounter("condition.bounds_check.skipped.num_parts"),
-		mSkippedPartSize: stats.GetCounter("condition.bounds_check.skipped.part_size"),
+		log:                  log.NewModule(".condition.bounds_check"),
+		stats:                stats,
+		maxParts:             conf.BoundsCheck.MaxParts,
+		maxPartSize:          conf.BoundsCheck.MaxPartSize,
+		minParts:             conf.BoundsCheck.MinParts,
+		minPartSize:          conf.BoundsCheck.MinPartSize,
+		maxTotalSize:         conf.BoundsCheck.MaxTotalSize,
+		minTotalSize:         conf.BoundsCheck.MinTotalSize,
+		maxMetadataEntries:   conf.BoundsCheck.MaxMetadataEntries,
+		maxMetadataValueSize: conf.BoundsCheck.MaxMetadataValueSize,
+		requireUTF8:          conf.BoundsCheck.RequireUTF8,
+		mApplied:             stats.GetCounter("condition.bounds_check.applied"),
+		mSkipped:             stats.GetCounter("condition.bounds_check.skipped"),
+		mSkippedEmpty:        stats.GetCounter("condition.bounds_check.skipped.empty_message"),
+		mSkippedNumParts:     stats.GetCounter("condition.bounds_check.skipped.num_parts"),
+		mSkippedPartSize:     stats.GetCounter("condition.bounds_check.skipped.part_size"),
+		mSkippedTotalSize:    stats.GetCounter("condition.bounds_check.skipped.total_size"),
+		mSkippedMetadata:     stats.GetCounter("condition.bounds_check.skipped.metadata"),
+		mSkippedEncoding:     stats.GetCounter("condition.bounds_check.skipped.encoding"),
 	}, nil
 }
 
@@ -127,21 +155,72 @@ func (c *BoundsCheck) Check(msg types.Message) bool {
 	}
 
 	var reject bool
+	var rejectMetric metrics.StatCounter
+	var totalSize int
 	msg.Iter(func(i int, p types.Part) error {
-		if size := len(p.Get()); size > c.maxPartSize || size < c.minPartSize {
+		partBytes := p.Get()
+
+		size := len(partBytes)
+		totalSize += size
+		if size > c.maxPartSize || size < c.minPartSize {
 			c.log.Debugf(
 				"Rejecting message due to message part size (%v -> %v): %v\n",
 				c.minPartSize, c.maxPartSize, size,
 			)
 			reject = true
+			rejectMetric = c.mSkippedPartSize
 			return errors.New("bounds_check part error")
 		}
+
+		if c.requireUTF8 && !utf8.Valid(partBytes) {
+			c.log.Debugf("Rejecting message due to invalid UTF-8 in part: %v\n", i)
+			reject = true
+			rejectMetric = c.mSkippedEncoding
+			return errors.New("bounds_check encoding error")
+		}
+
+		if c.maxMetadataEntries > 0 || c.maxMetadataValueSize > 0 {
+			var numEntries int
+			if metaErr := p.Metadata().Iter(func(k, v string) error {
+				numEntries++
+				if c.maxMetadataEntries > 0 && numEntries > c.maxMetadataEntries {
+					return errors.New("bounds_check metadata entries error")
+				}
+				if c.maxMetadataValueSize > 0 && len(v) > c.maxMetadataValueSize {
+					return errors.New("bounds_check metadata value error")
+				}
+				return nil
+			}); metaErr != nil {
+				c.log.Debugf("Rejecting message due to metadata bounds on part: %v\n", i)
+				reject = true
+				rejectMetric = c.mSkippedMetadata
+				return metaErr
+			}
+		}
+
 		return nil
 	})
 
+	if !reject && c.maxTotalSize > 0 && totalSize > c.maxTotalSize {
+		c.log.Debugf(
+			"Rejecting message due to total size exceeding limit (%v): %v\n",
+			c.maxTotalSize, totalSize,
+		)
+		reject = true
+		rejectMetric = c.mSkippedTotalSize
+	}
+	if !reject && c.minTotalSize > 0 && totalSize < c.minTotalSize {
+		c.log.Debugf(
+			"Rejecting message due to total size below minimum (%v): %v\n",
+			c.minTotalSize, totalSize,
+		)
+		reject = true
+		rejectMetric = c.mSkippedTotalSize
+	}
+
 	if reject {
 		c.mSkipped.Incr(1)
-		c.mSkippedPartSize.Incr(1)
+		rejectMetric.Incr(1)
 		return false
 	}
 
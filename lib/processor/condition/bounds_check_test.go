@@ -0,0 +1,193 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package condition
+
+import (
+	"testing"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// testCounter is a minimal metrics.StatCounter that records how many times
+// it was incremented, so tests can assert on exactly which skip counter
+// fired.
+type testCounter struct {
+	count int64
+}
+
+func (c *testCounter) Incr(i int64) error {
+	c.count += i
+	return nil
+}
+
+func newTestBoundsCheck(conf BoundsCheckConfig) (*BoundsCheck, map[string]*testCounter) {
+	counters := map[string]*testCounter{
+		"applied":    {},
+		"empty":      {},
+		"num_parts":  {},
+		"part_size":  {},
+		"total_size": {},
+		"metadata":   {},
+		"encoding":   {},
+		"skipped":    {},
+	}
+	return &BoundsCheck{
+		log:                  log.Noop(),
+		stats:                metrics.DudType{},
+		maxParts:             conf.MaxParts,
+		maxPartSize:          conf.MaxPartSize,
+		minParts:             conf.MinParts,
+		minPartSize:          conf.MinPartSize,
+		maxTotalSize:         conf.MaxTotalSize,
+		minTotalSize:         conf.MinTotalSize,
+		maxMetadataEntries:   conf.MaxMetadataEntries,
+		maxMetadataValueSize: conf.MaxMetadataValueSize,
+		requireUTF8:          conf.RequireUTF8,
+		mApplied:             counters["applied"],
+		mSkipped:             counters["skipped"],
+		mSkippedEmpty:        counters["empty"],
+		mSkippedNumParts:     counters["num_parts"],
+		mSkippedPartSize:     counters["part_size"],
+		mSkippedTotalSize:    counters["total_size"],
+		mSkippedMetadata:     counters["metadata"],
+		mSkippedEncoding:     counters["encoding"],
+	}, counters
+}
+
+func TestBoundsCheckDefaultsPreserveExistingBehaviour(t *testing.T) {
+	conf := NewBoundsCheckConfig()
+	cond, counters := newTestBoundsCheck(conf)
+
+	msg := types.NewMessage([][]byte{[]byte("hello world")})
+	if !cond.Check(msg) {
+		t.Error("expected message within default bounds to be accepted")
+	}
+	if counters["applied"].count != 1 {
+		t.Errorf("expected applied counter to fire once, got %v", counters["applied"].count)
+	}
+	if counters["total_size"].count != 0 || counters["metadata"].count != 0 || counters["encoding"].count != 0 {
+		t.Error("expected new bound counters to stay at zero when the new fields are left at their defaults")
+	}
+}
+
+func TestBoundsCheckMaxTotalSize(t *testing.T) {
+	conf := NewBoundsCheckConfig()
+	conf.MaxPartSize = 1000
+	conf.MaxTotalSize = 10
+	cond, counters := newTestBoundsCheck(conf)
+
+	msg := types.NewMessage([][]byte{[]byte("hello"), []byte("world!!")})
+	if cond.Check(msg) {
+		t.Error("expected message exceeding max_total_size to be rejected")
+	}
+	if counters["total_size"].count != 1 {
+		t.Errorf("expected skipped.total_size counter to fire once, got %v", counters["total_size"].count)
+	}
+}
+
+func TestBoundsCheckMinTotalSize(t *testing.T) {
+	conf := NewBoundsCheckConfig()
+	conf.MinTotalSize = 100
+	cond, counters := newTestBoundsCheck(conf)
+
+	msg := types.NewMessage([][]byte{[]byte("hi")})
+	if cond.Check(msg) {
+		t.Error("expected message below min_total_size to be rejected")
+	}
+	if counters["total_size"].count != 1 {
+		t.Errorf("expected skipped.total_size counter to fire once, got %v", counters["total_size"].count)
+	}
+}
+
+func TestBoundsCheckMaxMetadataEntries(t *testing.T) {
+	conf := NewBoundsCheckConfig()
+	conf.MaxMetadataEntries = 1
+	cond, counters := newTestBoundsCheck(conf)
+
+	msg := types.NewMessage([][]byte{[]byte("hello")})
+	msg.Get(0).Metadata().Set("foo", "1").Set("bar", "2")
+
+	if cond.Check(msg) {
+		t.Error("expected message exceeding max_metadata_entries to be rejected")
+	}
+	if counters["metadata"].count != 1 {
+		t.Errorf("expected skipped.metadata counter to fire once, got %v", counters["metadata"].count)
+	}
+}
+
+func TestBoundsCheckMaxMetadataValueSize(t *testing.T) {
+	conf := NewBoundsCheckConfig()
+	conf.MaxMetadataValueSize = 4
+	cond, counters := newTestBoundsCheck(conf)
+
+	msg := types.NewMessage([][]byte{[]byte("hello")})
+	msg.Get(0).Metadata().Set("foo", "too long")
+
+	if cond.Check(msg) {
+		t.Error("expected message exceeding max_metadata_value_size to be rejected")
+	}
+	if counters["metadata"].count != 1 {
+		t.Errorf("expected skipped.metadata counter to fire once, got %v", counters["metadata"].count)
+	}
+}
+
+func TestBoundsCheckRequireUTF8(t *testing.T) {
+	conf := NewBoundsCheckConfig()
+	conf.RequireUTF8 = true
+	cond, counters := newTestBoundsCheck(conf)
+
+	msg := types.NewMessage([][]byte{{0xff, 0xfe, 0xfd}})
+	if cond.Check(msg) {
+		t.Error("expected message with invalid UTF-8 to be rejected")
+	}
+	if counters["encoding"].count != 1 {
+		t.Errorf("expected skipped.encoding counter to fire once, got %v", counters["encoding"].count)
+	}
+
+	validMsg := types.NewMessage([][]byte{[]byte("hello world")})
+	if !cond.Check(validMsg) {
+		t.Error("expected message with valid UTF-8 to be accepted")
+	}
+}
+
+func TestBoundsCheckPartSizeShortCircuitsTotalSize(t *testing.T) {
+	conf := NewBoundsCheckConfig()
+	conf.MaxPartSize = 5
+	conf.MaxTotalSize = 5
+	cond, counters := newTestBoundsCheck(conf)
+
+	msg := types.NewMessage([][]byte{[]byte("this part is far too large")})
+	if cond.Check(msg) {
+		t.Error("expected message with an oversized part to be rejected")
+	}
+	if counters["part_size"].count != 1 {
+		t.Errorf("expected skipped.part_size counter to fire, got %v", counters["part_size"].count)
+	}
+	if counters["total_size"].count != 0 {
+		t.Error("expected skipped.total_size to stay at zero once a per-part violation already rejected the message")
+	}
+}
+
+//------------------------------------------------------------------------------
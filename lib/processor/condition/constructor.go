@@ -0,0 +1,98 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package condition
+
+import (
+	"errors"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// ErrInvalidConditionType is returned when a configuration references a
+// condition type that has not been registered in Constructors.
+var ErrInvalidConditionType = errors.New("invalid condition type")
+
+//------------------------------------------------------------------------------
+
+// Type reads a message and returns a bool (true or false), conditions are
+// used for filtering and branching messages.
+type Type interface {
+	// Check tests a message and returns true or false.
+	Check(msg types.Message) bool
+}
+
+//------------------------------------------------------------------------------
+
+// TypeSpec is a constructor and a usage description for each condition type.
+type TypeSpec struct {
+	constructor func(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error)
+	description string
+}
+
+// Constructors is a map of all condition types with their specs.
+var Constructors = map[string]TypeSpec{}
+
+//------------------------------------------------------------------------------
+
+// String constants representing each condition type.
+const (
+	TypeBoundsCheck = "bounds_check"
+	TypeThroughput  = "throughput"
+)
+
+//------------------------------------------------------------------------------
+
+// Config is the all encompassing configuration struct for all condition
+// types. Specific condition configs are stored in a dedicated field of the
+// same name, the chosen condition is determined by the Type field.
+type Config struct {
+	Type        string            `json:"type" yaml:"type"`
+	BoundsCheck BoundsCheckConfig `json:"bounds_check" yaml:"bounds_check"`
+	Throughput  ThroughputConfig  `json:"throughput" yaml:"throughput"`
+}
+
+// NewConfig returns a configuration struct fully populated with default
+// values.
+func NewConfig() Config {
+	return Config{
+		Type:        TypeBoundsCheck,
+		BoundsCheck: NewBoundsCheckConfig(),
+		Throughput:  NewThroughputConfig(),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// New creates a condition type based on a configuration.
+func New(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	if c, ok := Constructors[conf.Type]; ok {
+		return c.constructor(conf, mgr, log, stats)
+	}
+	return nil, ErrInvalidConditionType
+}
+
+//------------------------------------------------------------------------------
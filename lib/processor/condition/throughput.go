@@ -0,0 +1,185 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package condition
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeThroughput] = TypeSpec{
+		constructor: NewThroughput,
+		description: `
+Throughput is a condition that tracks the rate of messages and bytes flowing
+through it and returns true only whilst that rate remains within configured
+limits. Instantaneous samples are smoothed with an exponential moving average
+(controlled by 'smoothing_window_sec') in order to avoid single bursts or
+lulls from flapping the condition, making it useful for gating a
+` + "`filter`" + ` or ` + "`switch`" + ` on backpressure without wiring up an
+external rate limiter.`,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// ThroughputConfig contains configuration fields for the Throughput
+// condition.
+type ThroughputConfig struct {
+	MaxMsgsPerSec   float64 `json:"max_msgs_per_sec" yaml:"max_msgs_per_sec"`
+	MaxBytesPerSec  float64 `json:"max_bytes_per_sec" yaml:"max_bytes_per_sec"`
+	SmoothingWindow float64 `json:"smoothing_window_sec" yaml:"smoothing_window_sec"`
+}
+
+// NewThroughputConfig returns a ThroughputConfig with default values.
+func NewThroughputConfig() ThroughputConfig {
+	return ThroughputConfig{
+		MaxMsgsPerSec:   0,
+		MaxBytesPerSec:  0,
+		SmoothingWindow: 1,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Throughput is a condition that gates messages on an exponentially smoothed
+// rate of throughput, measured in both messages and bytes per second.
+type Throughput struct {
+	log   log.Modular
+	stats metrics.Type
+
+	maxMsgsPerSec  float64
+	maxBytesPerSec float64
+	tau            float64
+
+	nowFn func() time.Time
+
+	mut         sync.Mutex
+	lastSample  time.Time
+	totalMsgs   int64
+	totalBytes  int64
+	emaMsgRate  float64
+	emaByteRate float64
+
+	mApplied          metrics.StatCounter
+	mSkipped          metrics.StatCounter
+	mSkippedRate      metrics.StatCounter
+	mSkippedBytes     metrics.StatCounter
+	mRateEMAGauge     metrics.StatGauge
+	mByteRateEMAGauge metrics.StatGauge
+	mTotalMsgsGauge   metrics.StatGauge
+	mTotalBytesGauge  metrics.StatGauge
+}
+
+// NewThroughput returns a Throughput condition.
+func NewThroughput(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	tau := conf.Throughput.SmoothingWindow
+	if tau <= 0 {
+		tau = 1
+	}
+	return &Throughput{
+		log:               log.NewModule(".condition.throughput"),
+		stats:             stats,
+		maxMsgsPerSec:     conf.Throughput.MaxMsgsPerSec,
+		maxBytesPerSec:    conf.Throughput.MaxBytesPerSec,
+		tau:               tau,
+		nowFn:             time.Now,
+		lastSample:        time.Now(),
+		mApplied:          stats.GetCounter("condition.throughput.applied"),
+		mSkipped:          stats.GetCounter("condition.throughput.skipped"),
+		mSkippedRate:      stats.GetCounter("condition.throughput.skipped.rate_exceeded"),
+		mSkippedBytes:     stats.GetCounter("condition.throughput.skipped.bytes_exceeded"),
+		mRateEMAGauge:     stats.GetGauge("condition.throughput.rate_ema"),
+		mByteRateEMAGauge: stats.GetGauge("condition.throughput.byte_rate_ema"),
+		mTotalMsgsGauge:   stats.GetGauge("condition.throughput.total_msgs"),
+		mTotalBytesGauge:  stats.GetGauge("condition.throughput.total_bytes"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Check attempts to check a message against the configured throughput
+// bounds.
+func (t *Throughput) Check(msg types.Message) bool {
+	var msgBytes int64
+	msg.Iter(func(i int, p types.Part) error {
+		msgBytes += int64(len(p.Get()))
+		return nil
+	})
+
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	now := t.nowFn()
+	dt := now.Sub(t.lastSample).Seconds()
+	if dt <= 0 {
+		dt = 1e-9
+	}
+	t.lastSample = now
+
+	t.totalMsgs++
+	t.totalBytes += msgBytes
+	t.mTotalMsgsGauge.Set(t.totalMsgs)
+	t.mTotalBytesGauge.Set(t.totalBytes)
+
+	msgSample := 1 / dt
+	byteSample := float64(msgBytes) / dt
+
+	a := 1 - math.Exp(-dt/t.tau)
+	t.emaMsgRate += a * (msgSample - t.emaMsgRate)
+	t.emaByteRate += a * (byteSample - t.emaByteRate)
+
+	t.mRateEMAGauge.Set(int64(math.Round(t.emaMsgRate)))
+	t.mByteRateEMAGauge.Set(int64(math.Round(t.emaByteRate)))
+
+	if t.maxMsgsPerSec > 0 && t.emaMsgRate > t.maxMsgsPerSec {
+		t.log.Debugf(
+			"Rejecting message due to message rate exceeding limit (%v): %v\n",
+			t.maxMsgsPerSec, t.emaMsgRate,
+		)
+		t.mSkipped.Incr(1)
+		t.mSkippedRate.Incr(1)
+		return false
+	}
+	if t.maxBytesPerSec > 0 && t.emaByteRate > t.maxBytesPerSec {
+		t.log.Debugf(
+			"Rejecting message due to byte rate exceeding limit (%v): %v\n",
+			t.maxBytesPerSec, t.emaByteRate,
+		)
+		t.mSkipped.Incr(1)
+		t.mSkippedBytes.Incr(1)
+		return false
+	}
+
+	t.mApplied.Incr(1)
+	return true
+}
+
+//------------------------------------------------------------------------------
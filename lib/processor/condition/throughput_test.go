@@ -0,0 +1,166 @@
+// Copyright (c) 2018 Ashley Jeffs
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package condition
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/lib/log"
+	"github.com/Jeffail/benthos/lib/metrics"
+	"github.com/Jeffail/benthos/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// fakeClock lets tests drive Throughput.Check with deterministic, monotonic
+// Δt without sleeping in real time.
+type fakeClock struct {
+	t time.Time
+}
+
+func (f *fakeClock) Advance(d time.Duration) time.Time {
+	f.t = f.t.Add(d)
+	return f.t
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.t
+}
+
+func newTestThroughput(maxMsgsPerSec, maxBytesPerSec, smoothingWindow float64) (*Throughput, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	tau := smoothingWindow
+	if tau <= 0 {
+		tau = 1
+	}
+	return &Throughput{
+		log:               log.Noop(),
+		stats:             metrics.DudType{},
+		maxMsgsPerSec:     maxMsgsPerSec,
+		maxBytesPerSec:    maxBytesPerSec,
+		tau:               tau,
+		nowFn:             clock.Now,
+		lastSample:        clock.Now(),
+		mApplied:          metrics.DudType{}.GetCounter("applied"),
+		mSkipped:          metrics.DudType{}.GetCounter("skipped"),
+		mSkippedRate:      metrics.DudType{}.GetCounter("skipped.rate_exceeded"),
+		mSkippedBytes:     metrics.DudType{}.GetCounter("skipped.bytes_exceeded"),
+		mRateEMAGauge:     metrics.DudType{}.GetGauge("rate_ema"),
+		mByteRateEMAGauge: metrics.DudType{}.GetGauge("byte_rate_ema"),
+		mTotalMsgsGauge:   metrics.DudType{}.GetGauge("total_msgs"),
+		mTotalBytesGauge:  metrics.DudType{}.GetGauge("total_bytes"),
+	}, clock
+}
+
+func TestThroughputDefaultsSmoothingWindow(t *testing.T) {
+	conf := NewConfig()
+	conf.Throughput.SmoothingWindow = 0
+
+	condUntyped, err := NewThroughput(conf, nil, log.Noop(), metrics.DudType{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cond, ok := condUntyped.(*Throughput)
+	if !ok {
+		t.Fatalf("expected *Throughput, got %T", condUntyped)
+	}
+	if cond.tau != 1 {
+		t.Errorf("expected tau to default to 1, got %v", cond.tau)
+	}
+}
+
+func TestThroughputGatesOnMessageRate(t *testing.T) {
+	cond, clock := newTestThroughput(2, 0, 1)
+
+	msg := types.NewMessage([][]byte{[]byte("x")})
+
+	var lastResult bool
+	for i := 0; i < 50; i++ {
+		clock.Advance(10 * time.Millisecond) // 100 msgs/sec instantaneous sample
+		lastResult = cond.Check(msg)
+	}
+
+	if lastResult {
+		t.Fatal("expected condition to reject once the EMA message rate climbed above max_msgs_per_sec")
+	}
+	if cond.emaMsgRate <= cond.maxMsgsPerSec {
+		t.Errorf("expected EMA message rate (%v) to have risen above the limit (%v)", cond.emaMsgRate, cond.maxMsgsPerSec)
+	}
+}
+
+func TestThroughputGatesOnByteRate(t *testing.T) {
+	cond, clock := newTestThroughput(0, 100, 1)
+
+	bigPart := make([]byte, 1000)
+	msg := types.NewMessage([][]byte{bigPart})
+
+	var lastResult bool
+	for i := 0; i < 50; i++ {
+		clock.Advance(10 * time.Millisecond) // 100,000 bytes/sec instantaneous sample
+		lastResult = cond.Check(msg)
+	}
+
+	if lastResult {
+		t.Fatal("expected condition to reject once the EMA byte rate climbed above max_bytes_per_sec")
+	}
+	if cond.emaByteRate <= cond.maxBytesPerSec {
+		t.Errorf("expected EMA byte rate (%v) to have risen above the limit (%v)", cond.emaByteRate, cond.maxBytesPerSec)
+	}
+}
+
+func TestThroughputStaysWithinLimits(t *testing.T) {
+	cond, clock := newTestThroughput(1000, 1000000, 1)
+
+	msg := types.NewMessage([][]byte{[]byte("x")})
+
+	for i := 0; i < 10; i++ {
+		clock.Advance(100 * time.Millisecond)
+		if !cond.Check(msg) {
+			t.Fatalf("expected condition to remain true on iteration %v while comfortably within limits", i)
+		}
+	}
+}
+
+func TestThroughputConcurrentCheck(t *testing.T) {
+	cond, _ := newTestThroughput(0, 0, 1)
+
+	msg := types.NewMessage([][]byte{[]byte("hello world")})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				cond.Check(msg)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if exp, act := int64(50*50), cond.totalMsgs; exp != act {
+		t.Errorf("wrong total message count after concurrent Check calls: expected %v, got %v", exp, act)
+	}
+}
+
+//------------------------------------------------------------------------------